@@ -0,0 +1,216 @@
+//go:build geos
+
+// This file is gated behind the "geos" build tag because
+// github.com/paulsmith/gogeos/geos is a cgo binding that requires the
+// system libgeos-dev package (geos_c.h) to be installed. Build with
+// `go build -tags geos ./...` on a machine that has libgeos-dev installed
+// to include RouteBuffer; the rest of the module builds without it.
+package routeboxer
+
+import (
+	"fmt"
+
+	"github.com/paulmach/go.geo"
+	"github.com/paulsmith/gogeos/geos"
+)
+
+// RouteBuffer produces a true buffered polygon along a polyline, as an
+// alternative to the axis-aligned boxes produced by RouteBoxer. Users who
+// want a tight corridor for reverse-geocoding queries get the actual
+// buffered shape instead of the (often much larger) bounding boxes.
+type RouteBuffer struct {
+	distanceRange float64      // The distance in kms around the route that the buffer must cover.
+	vertices      geo.PointSet // Array of LatLngs representing the vertices of the path
+}
+
+// RouteBufferResult is the corridor polygon(s) produced by Buffer. It holds
+// more than one RouteBufferPolygon when the buffered route is disjoint, e.g.
+// a sharply zigzagging or self-intersecting route whose buffer comes back
+// from GEOS as a MultiPolygon rather than a single Polygon.
+type RouteBufferResult struct {
+	Polygons []RouteBufferPolygon
+}
+
+// RouteBufferPolygon is a single buffered polygon: an outer ring plus zero
+// or more holes, all in lat/lng order.
+type RouteBufferPolygon struct {
+	Outer geo.PointSet
+	Holes []geo.PointSet
+}
+
+func NewRouteBuffer(distanceRange float64, vertices geo.PointSet) *RouteBuffer {
+	return &RouteBuffer{distanceRange, vertices}
+}
+
+/**
+ * Buffer produces the corridor polygon around the route.
+ *
+ * The route is projected to a local azimuthal-equidistant projection
+ * centered on the center of its bounding box, so that the buffer distance can be applied in
+ * metres without the distortion of working directly in lat/lng. GEOS then
+ * buffers the projected line, and the resulting ring(s) are unprojected
+ * back to lat/lng.
+ */
+func (r *RouteBuffer) Buffer() (RouteBufferResult, error) {
+	center := r.vertices.Bound().Center()
+	proj := newAEQDProjection(*center)
+
+	coords := make([]geos.Coord, len(r.vertices))
+	for i, p := range r.vertices {
+		x, y := proj.project(p)
+		coords[i] = geos.Coord{X: x, Y: y}
+	}
+
+	line, err := geos.NewLineString(coords...)
+	if err != nil {
+		return RouteBufferResult{}, err
+	}
+
+	buffered, err := line.Buffer(r.distanceRange * 1000)
+	if err != nil {
+		return RouteBufferResult{}, err
+	}
+
+	geomType, err := buffered.Type()
+	if err != nil {
+		return RouteBufferResult{}, err
+	}
+
+	switch geomType {
+	case geos.POLYGON:
+		polygon, err := proj.unprojectPolygon(buffered)
+		if err != nil {
+			return RouteBufferResult{}, err
+		}
+		return RouteBufferResult{Polygons: []RouteBufferPolygon{polygon}}, nil
+	case geos.MULTIPOLYGON:
+		n, err := buffered.NGeometry()
+		if err != nil {
+			return RouteBufferResult{}, err
+		}
+
+		polygons := make([]RouteBufferPolygon, n)
+		for i := 0; i < n; i++ {
+			part, err := buffered.Geometry(i)
+			if err != nil {
+				return RouteBufferResult{}, err
+			}
+			polygons[i], err = proj.unprojectPolygon(part)
+			if err != nil {
+				return RouteBufferResult{}, err
+			}
+		}
+		return RouteBufferResult{Polygons: polygons}, nil
+	default:
+		return RouteBufferResult{}, fmt.Errorf("routeboxer: unexpected buffer geometry type %v", geomType)
+	}
+}
+
+// unprojectPolygon reads a single GEOS Polygon's shell and holes and
+// unprojects them back to lat/lng. It is the shared per-polygon step behind
+// both the POLYGON and MULTIPOLYGON (applied to each element) cases of
+// Buffer.
+func (a aeqdProjection) unprojectPolygon(polygon *geos.Geometry) (RouteBufferPolygon, error) {
+	shell, err := polygon.Shell()
+	if err != nil {
+		return RouteBufferPolygon{}, err
+	}
+
+	outer, err := a.unprojectRing(shell)
+	if err != nil {
+		return RouteBufferPolygon{}, err
+	}
+
+	holeGeoms, err := polygon.Holes()
+	if err != nil {
+		return RouteBufferPolygon{}, err
+	}
+
+	holes := make([]geo.PointSet, len(holeGeoms))
+	for i, hole := range holeGeoms {
+		ring, err := a.unprojectRing(hole)
+		if err != nil {
+			return RouteBufferPolygon{}, err
+		}
+		holes[i] = ring
+	}
+
+	return RouteBufferPolygon{Outer: outer, Holes: holes}, nil
+}
+
+// ToGeoJson emits the buffer as a GeoJSON Feature whose geometry is a
+// Polygon (outer ring plus holes) if Buffer produced a single polygon, or a
+// MultiPolygon if the route's buffer came back from GEOS as several
+// disjoint polygons.
+func (r RouteBufferResult) ToGeoJson() BufferFeature {
+	geometry := BufferGeometry{Type: "Polygon", Coordinates: [][]geo.Point{}}
+	if len(r.Polygons) > 1 {
+		geometry.Type = "MultiPolygon"
+		polygons := make([][][]geo.Point, len(r.Polygons))
+		for i, polygon := range r.Polygons {
+			polygons[i] = polygonRings(polygon)
+		}
+		geometry.Coordinates = polygons
+	} else if len(r.Polygons) == 1 {
+		geometry.Coordinates = polygonRings(r.Polygons[0])
+	}
+
+	return BufferFeature{
+		Type:       "Feature",
+		Geometry:   geometry,
+		Properties: map[string]interface{}{},
+	}
+}
+
+// polygonRings returns a single polygon's outer ring plus its holes, each
+// closed, in the ring order a GeoJSON Polygon's coordinates expect.
+func polygonRings(polygon RouteBufferPolygon) [][]geo.Point {
+	rings := make([][]geo.Point, 0, 1+len(polygon.Holes))
+	rings = append(rings, closedRing(polygon.Outer))
+	for _, hole := range polygon.Holes {
+		rings = append(rings, closedRing(hole))
+	}
+	return rings
+}
+
+// BufferGeometry is a GeoJSON Polygon or MultiPolygon geometry.
+type BufferGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// BufferFeature is a GeoJSON Feature wrapping a BufferGeometry.
+type BufferFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   BufferGeometry         `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// BufferFeatureCollection groups several RouteBufferResults, e.g. the
+// buffers for the segments of a route that was split at the anti-meridian.
+type BufferFeatureCollection struct {
+	Type     string          `json:"type"`
+	Features []BufferFeature `json:"features"`
+}
+
+func closedRing(points geo.PointSet) []geo.Point {
+	ring := make([]geo.Point, len(points), len(points)+1)
+	copy(ring, points)
+	if len(ring) > 0 && !ring[0].Equals(&ring[len(ring)-1]) {
+		ring = append(ring, ring[0])
+	}
+	return ring
+}
+
+func (a aeqdProjection) unprojectRing(ring *geos.Geometry) (geo.PointSet, error) {
+	coords, err := ring.Coords()
+	if err != nil {
+		return nil, err
+	}
+
+	points := make(geo.PointSet, len(coords))
+	for i, c := range coords {
+		points[i] = a.unproject(c.X, c.Y)
+	}
+	return points, nil
+}
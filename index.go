@@ -0,0 +1,209 @@
+package routeboxer
+
+import (
+	"math"
+	"sort"
+
+	"github.com/paulmach/go.geo"
+)
+
+// BoxIndex is an in-memory spatial index over a RouteBoxerResult. Building
+// it once with Index() and querying it many times turns RouteBoxer from a
+// one-shot box generator into a reusable geofence that can be tested against
+// a live stream of points or routes.
+//
+// Queries are pruned by bisecting a longitude-sorted index rather than
+// scanning every box: a box can only be relevant to a query if its western
+// edge is at or west of the query's eastern edge, and sort.Search finds the
+// cutoff in that sorted order in O(log n) instead of a full O(n) pass.
+// Boxes stitchAntimeridianBoxes has extended past +180° are kept in a
+// separate, always-checked list, since their western edge alone doesn't
+// say which queries they can match once a point has to be shifted by +360°
+// to compare against them.
+type BoxIndex struct {
+	boxes RouteBoxerResult
+
+	byMinLng []int     // indices into boxes, sorted by boxes[i].SouthWest().Lng() ascending
+	minLngs  []float64 // parallel to byMinLng, kept sorted for sort.Search
+
+	stitched []int // indices of boxes extended past +180°; there are only ever a handful, so these are checked unconditionally
+}
+
+// Index builds a BoxIndex over the merged boxes.
+func (r RouteBoxerResult) Index() *BoxIndex {
+	idx := &BoxIndex{boxes: r, byMinLng: make([]int, len(r))}
+
+	for i, box := range r {
+		idx.byMinLng[i] = i
+		if box.NorthEast().Lng() > 180 {
+			idx.stitched = append(idx.stitched, i)
+		}
+	}
+
+	sort.Slice(idx.byMinLng, func(i, j int) bool {
+		return r[idx.byMinLng[i]].SouthWest().Lng() < r[idx.byMinLng[j]].SouthWest().Lng()
+	})
+
+	idx.minLngs = make([]float64, len(r))
+	for i, bi := range idx.byMinLng {
+		idx.minLngs[i] = r[bi].SouthWest().Lng()
+	}
+
+	return idx
+}
+
+// candidates returns, in ascending box-index order, every box that could
+// possibly match a query whose eastern edge is maxLng: every box whose
+// western edge is at or west of maxLng (found by bisecting minLngs), plus
+// the always-checked stitched boxes.
+func (idx *BoxIndex) candidates(maxLng float64) []int {
+	n := sort.Search(len(idx.minLngs), func(i int) bool { return idx.minLngs[i] > maxLng })
+
+	seen := make(map[int]bool, n+len(idx.stitched))
+	hits := make([]int, 0, n+len(idx.stitched))
+	for _, i := range idx.byMinLng[:n] {
+		seen[i] = true
+		hits = append(hits, i)
+	}
+	for _, i := range idx.stitched {
+		if !seen[i] {
+			hits = append(hits, i)
+		}
+	}
+
+	sort.Ints(hits)
+	return hits
+}
+
+// Contains reports whether p falls within any of the indexed boxes.
+func (idx *BoxIndex) Contains(p geo.Point) bool {
+	for _, i := range idx.candidates(p.Lng()) {
+		if boxContains(idx.boxes[i], p) {
+			return true
+		}
+	}
+	return false
+}
+
+// IntersectsBound returns the indices of the boxes that overlap b.
+func (idx *BoxIndex) IntersectsBound(b geo.Bound) []int {
+	hits := []int{}
+	for _, i := range idx.candidates(b.NorthEast().Lng()) {
+		box := idx.boxes[i]
+		test := b
+		if box.NorthEast().Lng() > 180 && b.NorthEast().Lng() < box.SouthWest().Lng() {
+			sw := normalizeForBox(*b.SouthWest(), box)
+			ne := normalizeForBox(*b.NorthEast(), box)
+			test = *geo.NewBoundFromPoints(&sw, &ne)
+		}
+		if box.Intersects(&test) {
+			hits = append(hits, i)
+		}
+	}
+	return hits
+}
+
+// IntersectsPolyline returns the indices of the boxes that pts passes
+// through, either by having a vertex inside a box or by crossing one of its
+// edges.
+func (idx *BoxIndex) IntersectsPolyline(pts geo.PointSet) []int {
+	hits := []int{}
+	for _, i := range idx.candidates(pts.Bound().NorthEast().Lng()) {
+		if polylineIntersectsBox(pts, idx.boxes[i]) {
+			hits = append(hits, i)
+		}
+	}
+	return hits
+}
+
+func polylineIntersectsBox(pts geo.PointSet, box geo.Bound) bool {
+	if len(pts) == 0 {
+		return false
+	}
+
+	if boxContains(box, pts[0]) {
+		return true
+	}
+
+	for i := 1; i < len(pts); i++ {
+		if boxContains(box, pts[i]) || segmentIntersectsBox(pts[i-1], pts[i], box) {
+			return true
+		}
+	}
+	return false
+}
+
+func segmentIntersectsBox(a, b geo.Point, box geo.Bound) bool {
+	sw, ne := box.SouthWest(), box.NorthEast()
+	corners := [4]geo.Point{
+		*geo.NewPointFromLatLng(sw.Lat(), sw.Lng()),
+		*geo.NewPointFromLatLng(sw.Lat(), ne.Lng()),
+		*geo.NewPointFromLatLng(ne.Lat(), ne.Lng()),
+		*geo.NewPointFromLatLng(ne.Lat(), sw.Lng()),
+	}
+
+	a, b = normalizeForBox(a, box), normalizeForBox(b, box)
+
+	for i := 0; i < 4; i++ {
+		if segmentsIntersect(a, b, corners[i], corners[(i+1)%4]) {
+			return true
+		}
+	}
+	return false
+}
+
+// boxContains reports whether p falls within box, accounting for boxes that
+// stitchAntimeridianBoxes has extended past +180° longitude to represent a
+// corridor continuing across the ±180° seam rather than wrapping.
+func boxContains(box geo.Bound, p geo.Point) bool {
+	p = normalizeForBox(p, box)
+	return box.Contains(&p)
+}
+
+// normalizeForBox shifts p's longitude by +360° if box extends past +180°
+// (per stitchAntimeridianBoxes) and p would otherwise fall to the west of
+// the box's western edge, so points given in the usual -180..180 range are
+// still matched against the box's shifted range.
+func normalizeForBox(p geo.Point, box geo.Bound) geo.Point {
+	if box.NorthEast().Lng() > 180 && p.Lng() < box.SouthWest().Lng() {
+		return *geo.NewPointFromLatLng(p.Lat(), p.Lng()+360)
+	}
+	return p
+}
+
+// segmentsIntersect reports whether segments p1p2 and p3p4 cross, using the
+// standard orientation test (treating lat/lng as planar coordinates, as the
+// rest of the grid/box construction in this package already does).
+func segmentsIntersect(p1, p2, p3, p4 geo.Point) bool {
+	d1 := cross(p3, p4, p1)
+	d2 := cross(p3, p4, p2)
+	d3 := cross(p1, p2, p3)
+	d4 := cross(p1, p2, p4)
+
+	if ((d1 > 0) != (d2 > 0)) && ((d3 > 0) != (d4 > 0)) {
+		return true
+	}
+
+	if d1 == 0 && onSegment(p3, p4, p1) {
+		return true
+	}
+	if d2 == 0 && onSegment(p3, p4, p2) {
+		return true
+	}
+	if d3 == 0 && onSegment(p1, p2, p3) {
+		return true
+	}
+	if d4 == 0 && onSegment(p1, p2, p4) {
+		return true
+	}
+	return false
+}
+
+func cross(a, b, c geo.Point) float64 {
+	return (b.Lng()-a.Lng())*(c.Lat()-a.Lat()) - (b.Lat()-a.Lat())*(c.Lng()-a.Lng())
+}
+
+func onSegment(a, b, p geo.Point) bool {
+	return math.Min(a.Lng(), b.Lng()) <= p.Lng() && p.Lng() <= math.Max(a.Lng(), b.Lng()) &&
+		math.Min(a.Lat(), b.Lat()) <= p.Lat() && p.Lat() <= math.Max(a.Lat(), b.Lat())
+}
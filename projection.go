@@ -0,0 +1,50 @@
+package routeboxer
+
+import (
+	"math"
+
+	"github.com/paulmach/go.geo"
+)
+
+// aeqdProjection is an azimuthal-equidistant projection centered on a given
+// lat/lng point, used to work in metres without the distortion of
+// operating directly in lat/lng. It backs both the GEOS corridor buffer
+// (buffer.go) and the polar-safe grid construction (safegrid.go).
+type aeqdProjection struct {
+	centerLat float64
+	centerLng float64
+}
+
+func newAEQDProjection(center geo.Point) aeqdProjection {
+	return aeqdProjection{deg2rad(center.Lat()), deg2rad(center.Lng())}
+}
+
+func (a aeqdProjection) project(p geo.Point) (x, y float64) {
+	lat := deg2rad(p.Lat())
+	lng := deg2rad(p.Lng())
+
+	dLng := lng - a.centerLng
+	c := math.Acos(math.Sin(a.centerLat)*math.Sin(lat) + math.Cos(a.centerLat)*math.Cos(lat)*math.Cos(dLng))
+
+	if c < 1e-12 {
+		return 0, 0
+	}
+
+	k := c / math.Sin(c)
+	x = k * math.Cos(lat) * math.Sin(dLng) * geo.EarthRadius
+	y = k * (math.Cos(a.centerLat)*math.Sin(lat) - math.Sin(a.centerLat)*math.Cos(lat)*math.Cos(dLng)) * geo.EarthRadius
+	return x, y
+}
+
+func (a aeqdProjection) unproject(x, y float64) geo.Point {
+	rho := math.Hypot(x, y)
+	if rho < 1e-9 {
+		return *geo.NewPointFromLatLng(rad2deg(a.centerLat), rad2deg(a.centerLng))
+	}
+
+	c := rho / geo.EarthRadius
+	lat := math.Asin(math.Cos(c)*math.Sin(a.centerLat) + y*math.Sin(c)*math.Cos(a.centerLat)/rho)
+	lng := a.centerLng + math.Atan2(x*math.Sin(c), rho*math.Cos(a.centerLat)*math.Cos(c)-y*math.Sin(a.centerLat)*math.Sin(c))
+
+	return *geo.NewPointFromLatLng(rad2deg(lat), rad2deg(lng))
+}
@@ -36,7 +36,7 @@ func RhumbDestinationPoint(currentPoint geo.Point, brng float64, dist float64) *
 		}
 	}
 
-	lon2 := math.Remainder(lon1+dLon+3*math.Pi, (2*math.Pi)-math.Pi)
+	lon2 := math.Mod(lon1+dLon+3*math.Pi, 2*math.Pi) - math.Pi
 
 	return geo.NewPointFromLatLng(rad2deg(lat2), rad2deg(lon2))
 }
@@ -15,6 +15,9 @@ type RouteBoxer struct {
 	grid    [][]int
 	boxesX  []geo.Bound
 	boxesY  []geo.Bound
+
+	alignToGlobalGrid bool      // Whether the grid is snapped to a fixed origin instead of centered on the route
+	gridOrigin        geo.Point // The origin the grid is snapped to when alignToGlobalGrid is set
 }
 
 type RouteBoxerResult []geo.Bound
@@ -25,7 +28,20 @@ type GeoJsonResult struct {
 }
 
 func NewRouteBoxer(distanceRange float64, vertices geo.PointSet) *RouteBoxer {
-	return &RouteBoxer{distanceRange, vertices, []float64{}, []float64{}, nil, []geo.Bound{}, []geo.Bound{}}
+	return &RouteBoxer{distanceRange, vertices, []float64{}, []float64{}, nil, []geo.Bound{}, []geo.Bound{}, false, geo.Point{}}
+}
+
+// NewRouteBoxerFixedGrid returns a RouteBoxer whose grid lines are snapped to
+// multiples of distanceRange measured with rhumb offsets from the given
+// origin, instead of being centered on the route's own bounding box. Two
+// independent routes built with the same distanceRange and origin always
+// produce boxes that share grid lines, so callers can use the boxes as
+// deterministic, cacheable per-tile query keys.
+func NewRouteBoxerFixedGrid(distanceRange float64, vertices geo.PointSet, originLat, originLng float64) *RouteBoxer {
+	r := NewRouteBoxer(distanceRange, vertices)
+	r.alignToGlobalGrid = true
+	r.gridOrigin = *geo.NewPointFromLatLng(originLat, originLng)
+	return r
 }
 
 func (r *RouteBoxer) Boxes() RouteBoxerResult {
@@ -49,6 +65,11 @@ func (r *RouteBoxer) Boxes() RouteBoxerResult {
  * @param {Number} range The spacing of the grid cells.
  */
 func (r *RouteBoxer) buildGrid() {
+	if r.alignToGlobalGrid {
+		r.buildFixedGrid()
+		return
+	}
+
 	// Create a Bound object that contains the whole path
 	routeBounds := r.vertices.Bound()
 
@@ -91,6 +112,55 @@ func (r *RouteBoxer) buildGrid() {
 	}
 }
 
+/**
+ * Generates grid lines snapped to multiples of distanceRange measured from
+ * r.gridOrigin, rather than centered on the route's own bounding box. This
+ * mirrors the tile-bbox approach of snapping a tile's min/max to the
+ * floor/ceil of the tile width, so that boxes from independent routes built
+ * against the same origin share grid lines.
+ *
+ * Each direction grows two steps past where it first reaches the route
+ * bound, one step more than buildGrid's center-outward loops leave
+ * (getCellCoords' result is already one cell in from the grid line it found,
+ * and markCell marks that cell's neighbours on every side, so a vertex
+ * landing exactly on the outermost bound needs a line two steps further out,
+ * not one, to have both neighbours in range).
+ */
+func (r *RouteBoxer) buildFixedGrid() {
+	origin := r.gridOrigin
+	routeBounds := r.vertices.Bound()
+
+	latStep := RhumbDestinationPoint(origin, 0, r.distanceRange).Lat() - origin.Lat()
+
+	// Measured from a reference point on the prime meridian rather than from
+	// origin itself: the rhumb-line eastward step at a given latitude doesn't
+	// actually depend on starting longitude, and computing it at origin.Lng()
+	// breaks when origin sits on the ±180° seam, where RhumbDestinationPoint's
+	// wraparound would make a plain subtraction come out as a step of nearly
+	// 360° instead of a step of one distanceRange.
+	lngRef := *geo.NewPointFromLatLng(origin.Lat(), 0)
+	lngStep := RhumbDestinationPoint(lngRef, 90, r.distanceRange).Lng() - lngRef.Lng()
+
+	southLine := int(math.Floor((routeBounds.SouthWest().Lat()-origin.Lat())/latStep)) - 2
+	northLine := int(math.Ceil((routeBounds.NorthEast().Lat()-origin.Lat())/latStep)) + 2
+
+	for i := southLine; i <= northLine; i++ {
+		r.latGrid = append(r.latGrid, origin.Lat()+float64(i)*latStep)
+	}
+
+	westLine := int(math.Floor((routeBounds.SouthWest().Lng()-origin.Lng())/lngStep)) - 2
+	eastLine := int(math.Ceil((routeBounds.NorthEast().Lng()-origin.Lng())/lngStep)) + 2
+
+	for i := westLine; i <= eastLine; i++ {
+		r.lngGrid = append(r.lngGrid, origin.Lng()+float64(i)*lngStep)
+	}
+
+	r.grid = make([][]int, len(r.lngGrid))
+	for i := 0; i < len(r.lngGrid); i++ {
+		r.grid[i] = make([]int, len(r.latGrid))
+	}
+}
+
 /**
  * Find all of the cells in the overlaid grid that the path intersects
  *
@@ -0,0 +1,68 @@
+package routeboxer
+
+import (
+	"github.com/golang/geo/s2"
+	"github.com/paulmach/go.geo"
+)
+
+/**
+ * Returns an S2 covering of the route corridor in place of the axis-aligned
+ * boxes returned by Boxes. Each merged box is converted into an S2
+ * rectangle and covered separately by s2.RegionCoverer, bounded by minLevel,
+ * maxLevel and maxCells; the per-box coverings are then unioned and
+ * normalized. maxCells limits each box's covering, not the result, which can
+ * hold more than maxCells cells when Boxes returns several boxes.
+ *
+ * @param {int} minLevel The minimum S2 cell level the coverer may use
+ * @param {int} maxLevel The maximum S2 cell level the coverer may use
+ * @param {int} maxCells The maximum number of cells the coverer should return for each box
+ * @return {[]s2.CellID} The covering of the route corridor
+ */
+func (r *RouteBoxer) CellCovering(minLevel, maxLevel, maxCells int) []s2.CellID {
+	boxes := r.Boxes()
+
+	coverer := &s2.RegionCoverer{
+		MinLevel: minLevel,
+		MaxLevel: maxLevel,
+		MaxCells: maxCells,
+	}
+
+	var union s2.CellUnion
+	for _, box := range boxes {
+		union = s2.CellUnionFromUnion(union, coverer.Covering(boundToRect(box)))
+	}
+	union.Normalize()
+
+	return []s2.CellID(union)
+}
+
+// boundToRect converts a geo.Bound into the equivalent s2.Rect so it can be
+// passed to s2.RegionCoverer as a Region.
+func boundToRect(b geo.Bound) s2.Rect {
+	r := s2.RectFromLatLng(s2.LatLngFromDegrees(b.SouthWest().Lat(), b.SouthWest().Lng()))
+	return r.AddPoint(s2.LatLngFromDegrees(b.NorthEast().Lat(), b.NorthEast().Lng()))
+}
+
+// CellCoveringTokens serializes a covering as base-16 cell tokens, the format
+// expected by typical S2-backed spatial indexes (BigTable, Cassandra,
+// Elasticsearch geo_shape) when keying rows or documents by S2 cell prefix.
+func CellCoveringTokens(covering []s2.CellID) []string {
+	tokens := make([]string, len(covering))
+	for i, id := range covering {
+		tokens[i] = id.ToToken()
+	}
+	return tokens
+}
+
+// UnionCellCovering merges the coverings from multiple RouteBoxer runs (e.g.
+// independent routes that may overlap) into a single normalized covering, so
+// callers can issue one set of cell-prefix queries across all of them.
+func UnionCellCovering(coverings ...[]s2.CellID) []s2.CellID {
+	var union s2.CellUnion
+	for _, c := range coverings {
+		union = s2.CellUnionFromUnion(union, s2.CellUnion(c))
+	}
+	union.Normalize()
+
+	return []s2.CellID(union)
+}
@@ -0,0 +1,372 @@
+package routeboxer
+
+import (
+	"math"
+	"sort"
+
+	"github.com/paulmach/go.geo"
+)
+
+// poleLatitudeThreshold is the absolute latitude, in degrees, beyond which a
+// vertex is considered close enough to a pole that buildGrid's plain lat/lng
+// grid is unsafe and BoxesSafe falls back to a local projection instead.
+const poleLatitudeThreshold = 85.0
+
+// BoxesSafe computes corridor boxes the same way as Boxes, but first checks
+// for routes that cross the ±180° meridian or pass close to the poles,
+// cases where buildGrid's plain lat/lng grid breaks down: routeBounds
+// Center(), the monotonic growth of lngGrid, and getCellCoords' linear scans
+// all assume a continuous [-180,180] span with no nearby pole.
+//
+// Meridian-crossing routes are split into a west and an east segment at the
+// seam, each boxed independently, and the resulting boxes stitched back
+// together where they meet at ±180°. Routes with any vertex whose absolute
+// latitude exceeds poleLatitudeThreshold are instead boxed in a local
+// azimuthal-equidistant projection centered on the center of the route's
+// bounding box.
+func (r *RouteBoxer) BoxesSafe() RouteBoxerResult {
+	if r.nearPole() {
+		return r.boxesNearPole()
+	}
+
+	if r.crossesAntimeridian() {
+		return r.boxesAcrossAntimeridian()
+	}
+
+	return r.Boxes()
+}
+
+// crossesAntimeridian reports whether the route's vertices have a gap wider
+// than 180° when their longitudes are sorted, meaning the route actually
+// runs contiguously across the ±180° seam rather than through the interior
+// of the [-180,180] range.
+func (r *RouteBoxer) crossesAntimeridian() bool {
+	if len(r.vertices) < 2 {
+		return false
+	}
+
+	lngs := make([]float64, len(r.vertices))
+	for i, p := range r.vertices {
+		lngs[i] = p.Lng()
+	}
+	sort.Float64s(lngs)
+
+	for i := 1; i < len(lngs); i++ {
+		if lngs[i]-lngs[i-1] > 180 {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RouteBoxer) nearPole() bool {
+	for _, p := range r.vertices {
+		if math.Abs(p.Lat()) > poleLatitudeThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// boxesAcrossAntimeridian splits the route into an east segment (lng >= 0,
+// running up to +180°) and a west segment (lng < 0, running up from -180°),
+// with a synthetic vertex inserted exactly on the seam at each crossing so
+// both halves' grids actually get marked out to ±180 instead of stopping
+// wherever their real vertices happen to end. Each half is boxed with
+// NewRouteBoxerFixedGrid rather than a plain RouteBoxer, anchored to a
+// shared latitude origin so the two halves' latGrid lines agree
+// band-for-band at the seam: with each half centred on its own bounding box,
+// their lat bands would otherwise come from different origins and
+// essentially never line up.
+func (r *RouteBoxer) boxesAcrossAntimeridian() RouteBoxerResult {
+	east, west := r.splitAtAntimeridian()
+
+	originLat := r.vertices.Bound().Center().Lat()
+
+	boxes := RouteBoxerResult{}
+	if len(east) > 0 {
+		boxes = append(boxes, NewRouteBoxerFixedGrid(r.distanceRange, east, originLat, 180).Boxes()...)
+	}
+	if len(west) > 0 {
+		boxes = append(boxes, NewRouteBoxerFixedGrid(r.distanceRange, west, originLat, -180).Boxes()...)
+	}
+
+	return stitchAntimeridianBoxes(boxes)
+}
+
+// splitAtAntimeridian splits r.vertices into an east segment (lng >= 0) and
+// a west segment (lng < 0). At each point along the route where the sign
+// flips, a synthetic vertex is inserted at the interpolated latitude the
+// route actually crosses the seam at, on both segments (lng 180 for the
+// east segment, -180 for the west), so each half's grid is built knowing it
+// has to reach the seam rather than merely getting close to it.
+func (r *RouteBoxer) splitAtAntimeridian() (east, west geo.PointSet) {
+	for i, p := range r.vertices {
+		if i > 0 {
+			prev := r.vertices[i-1]
+			if (prev.Lng() >= 0) != (p.Lng() >= 0) {
+				lat := seamCrossingLat(prev, p)
+				east = append(east, *geo.NewPointFromLatLng(lat, 180))
+				west = append(west, *geo.NewPointFromLatLng(lat, -180))
+			}
+		}
+
+		if p.Lng() >= 0 {
+			east = append(east, p)
+		} else {
+			west = append(west, p)
+		}
+	}
+	return east, west
+}
+
+// seamCrossingLat linearly interpolates the latitude at which the segment
+// a->b, which straddles the ±180° seam, actually crosses it.
+func seamCrossingLat(a, b geo.Point) float64 {
+	aLng, bLng := a.Lng(), b.Lng()
+	if aLng < 0 {
+		aLng += 360
+	}
+	if bLng < 0 {
+		bLng += 360
+	}
+	if aLng == bLng {
+		return a.Lat()
+	}
+
+	t := (180 - aLng) / (bLng - aLng)
+	return a.Lat() + t*(b.Lat()-a.Lat())
+}
+
+// stitchAntimeridianBoxes merges pairs of boxes whose edges meet at the
+// ±180° seam and that span the same band of latitudes into a single box
+// that continues past +180° (e.g. a box running from 170° to 190° rather
+// than wrapping back to -170°), matching how antimeridian-crossing bounding
+// boxes are conventionally represented before a caller normalizes them for
+// display.
+func stitchAntimeridianBoxes(boxes RouteBoxerResult) RouteBoxerResult {
+	stitched := make(RouteBoxerResult, 0, len(boxes))
+	used := make([]bool, len(boxes))
+
+	for i := range boxes {
+		if used[i] {
+			continue
+		}
+
+		merged := boxes[i]
+		for j := i + 1; j < len(boxes); j++ {
+			if used[j] || !sameLatBand(merged, boxes[j]) {
+				continue
+			}
+
+			if math.Abs(merged.NorthEast().Lng()-180) < 0.001 && math.Abs(boxes[j].SouthWest().Lng()+180) < 0.001 {
+				merged = shiftedUnion(merged, boxes[j])
+				used[j] = true
+			} else if math.Abs(boxes[j].NorthEast().Lng()-180) < 0.001 && math.Abs(merged.SouthWest().Lng()+180) < 0.001 {
+				merged = shiftedUnion(boxes[j], merged)
+				used[j] = true
+			}
+		}
+
+		stitched = append(stitched, merged)
+	}
+
+	return stitched
+}
+
+func sameLatBand(a, b geo.Bound) bool {
+	return math.Abs(a.SouthWest().Lat()-b.SouthWest().Lat()) < 0.001 &&
+		math.Abs(a.NorthEast().Lat()-b.NorthEast().Lat()) < 0.001
+}
+
+// shiftedUnion joins an east-of-seam box (ending at +180°) with a
+// west-of-seam box (starting at -180°), shifting the west box's longitudes
+// by +360° so the union continues past +180° instead of wrapping.
+func shiftedUnion(eastBox, westBox geo.Bound) geo.Bound {
+	ne := geo.NewPointFromLatLng(eastBox.NorthEast().Lat(), westBox.NorthEast().Lng()+360)
+	merged := geo.NewBoundFromPoints(eastBox.SouthWest(), eastBox.NorthEast())
+	merged.Extend(ne)
+	return *merged
+}
+
+// boxesNearPole boxes the route in a local azimuthal-equidistant projection
+// centered on the center of the route's bounding box, where lat/lng distort too much near the
+// poles for buildGrid's rhumb-based grid to be meaningful. Each marked cell
+// is reprojected back to lat/lng as its own small bound, rather than merged
+// into larger rectangles the way Boxes does, since a merged box would no
+// longer be a good approximation of the projected square it came from.
+//
+// Consecutive vertices that land in non-adjacent cells have every cell their
+// segment passes through filled in, the same way FindIntersectingCells/
+// getGridIntersects does for the ordinary grid, so routes defined by sparse
+// waypoints (e.g. a trans-Arctic flight path) don't leave holes in the
+// corridor.
+func (r *RouteBoxer) boxesNearPole() RouteBoxerResult {
+	center := r.vertices.Bound().Center()
+	proj := newAEQDProjection(*center)
+	step := r.distanceRange * 1000
+
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	minY, maxY := math.Inf(1), math.Inf(-1)
+
+	coords := make([][2]float64, len(r.vertices))
+	for i, p := range r.vertices {
+		x, y := proj.project(p)
+		coords[i] = [2]float64{x, y}
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+
+	xGrid := buildPolarAxis(minX, maxX, step)
+	yGrid := buildPolarAxis(minY, maxY, step)
+
+	// xGrid/yGrid are aligned to step-sized cells starting at floor(min/step),
+	// not at minX/minY directly, so cell indices must be measured from that
+	// same aligned origin.
+	xOrigin, yOrigin := xGrid[0], yGrid[0]
+
+	marked := make([][]bool, len(xGrid)-1)
+	for i := range marked {
+		marked[i] = make([]bool, len(yGrid)-1)
+	}
+
+	markPolarCell := func(cx, cy int) {
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				x, y := cx+dx, cy+dy
+				if x >= 0 && x < len(marked) && y >= 0 && y < len(marked[0]) {
+					marked[x][y] = true
+				}
+			}
+		}
+	}
+
+	if len(coords) > 0 {
+		cx := polarCellIndex(coords[0][0], xOrigin, step, len(marked))
+		cy := polarCellIndex(coords[0][1], yOrigin, step, len(marked[0]))
+		markPolarCell(cx, cy)
+	}
+
+	for i := 1; i < len(coords); i++ {
+		for _, cell := range polarLineCells(coords[i-1], coords[i], xOrigin, yOrigin, step) {
+			markPolarCell(cell[0], cell[1])
+		}
+	}
+
+	boxes := RouteBoxerResult{}
+	for cx := range marked {
+		for cy := range marked[cx] {
+			if !marked[cx][cy] {
+				continue
+			}
+
+			corners := []geo.Point{
+				proj.unproject(xGrid[cx], yGrid[cy]),
+				proj.unproject(xGrid[cx+1], yGrid[cy]),
+				proj.unproject(xGrid[cx+1], yGrid[cy+1]),
+				proj.unproject(xGrid[cx], yGrid[cy+1]),
+			}
+
+			bound := geo.NewBoundFromPoints(&corners[0], &corners[0])
+			for i := 1; i < len(corners); i++ {
+				bound.Extend(&corners[i])
+			}
+			boxes = append(boxes, *bound)
+		}
+	}
+
+	return boxes
+}
+
+func buildPolarAxis(min, max, step float64) []float64 {
+	start := math.Floor(min/step) * step
+	end := math.Ceil(max/step) * step
+
+	axis := []float64{}
+	for v := start; v <= end+step/2; v += step {
+		axis = append(axis, v)
+	}
+	return axis
+}
+
+func polarCellIndex(v, min, step float64, count int) int {
+	i := int((v - min) / step)
+	if i < 0 {
+		return 0
+	}
+	if i >= count {
+		return count - 1
+	}
+	return i
+}
+
+// polarLineCells returns the (cx, cy) grid cell indices that the straight
+// line from a to b passes through, in the step-sized Cartesian grid anchored
+// at (xOrigin, yOrigin), using a voxel traversal (see axisStep) rather than
+// the grid-line intersections getGridIntersects/fillInGridSquares use on the
+// lat/lng grid for ordinary routes. It serves the same purpose: a segment between two vertices landing in non-adjacent
+// cells has every intervening cell filled in rather than just its endpoints.
+func polarLineCells(a, b [2]float64, xOrigin, yOrigin, step float64) [][2]int {
+	cx := int(math.Floor((a[0] - xOrigin) / step))
+	cy := int(math.Floor((a[1] - yOrigin) / step))
+	cx1 := int(math.Floor((b[0] - xOrigin) / step))
+	cy1 := int(math.Floor((b[1] - yOrigin) / step))
+
+	cells := [][2]int{{cx, cy}}
+
+	dx := b[0] - a[0]
+	dy := b[1] - a[1]
+
+	stepX, tMaxX, tDeltaX := axisStep(a[0], dx, cx, xOrigin, step)
+	stepY, tMaxY, tDeltaY := axisStep(a[1], dy, cy, yOrigin, step)
+
+	// Safety valve against a degenerate/zero-length segment or float
+	// rounding leaving cx/cy unable to reach cx1/cy1.
+	limit := 2 * (abs(cx1-cx) + abs(cy1-cy) + 2)
+
+	for n := 0; (cx != cx1 || cy != cy1) && n < limit; n++ {
+		if tMaxX < tMaxY {
+			cx += stepX
+			tMaxX += tDeltaX
+		} else {
+			cy += stepY
+			tMaxY += tDeltaY
+		}
+		cells = append(cells, [2]int{cx, cy})
+	}
+
+	return cells
+}
+
+// axisStep computes the traversal step direction and ray-grid parameters for
+// a single axis of polarLineCells' line walk (the Amanatides-Woo fast voxel
+// traversal).
+func axisStep(v0, d float64, cell int, origin, step float64) (stepDir int, tMax, tDelta float64) {
+	if d == 0 {
+		return 0, math.Inf(1), math.Inf(1)
+	}
+	if d > 0 {
+		stepDir = 1
+	} else {
+		stepDir = -1
+	}
+
+	nextBoundary := origin + float64(cell+boolToInt(stepDir > 0))*step
+	tMax = (nextBoundary - v0) / d
+	tDelta = step / math.Abs(d)
+	return stepDir, tMax, tDelta
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,84 @@
+package routeboxer
+
+import (
+	"testing"
+
+	"github.com/paulmach/go.geo"
+)
+
+// TestBoxesSafeBeringStrait checks that a route crossing the ±180° seam at
+// the Bering Strait is stitched into a single corridor that continues past
+// +180° instead of being left as two disjoint boxes either side of the
+// meridian.
+func TestBoxesSafeBeringStrait(t *testing.T) {
+	vertices := geo.PointSet{
+		*geo.NewPointFromLatLng(65.3, 178.0),
+		*geo.NewPointFromLatLng(65.5, 179.0),
+		*geo.NewPointFromLatLng(65.7, -179.0),
+		*geo.NewPointFromLatLng(66.0, -177.0),
+	}
+
+	boxes := NewRouteBoxer(50, vertices).BoxesSafe()
+	if len(boxes) == 0 {
+		t.Fatal("expected at least one box for a Bering Strait crossing route")
+	}
+
+	foundSeamCrossing := false
+	for _, b := range boxes {
+		if b.NorthEast().Lng() > 180 && b.SouthWest().Lng() < 180 {
+			foundSeamCrossing = true
+		}
+	}
+	if !foundSeamCrossing {
+		t.Error("expected a stitched box spanning the ±180° seam (NorthEast().Lng() > 180)")
+	}
+}
+
+// TestBoxesSafeTransArctic checks that a sparse-waypoint trans-Arctic flight
+// path, boxed via the polar-safe grid, produces a corridor with no holes:
+// every cell a segment between two waypoints passes through must be marked,
+// not just the cells each waypoint itself lands in.
+func TestBoxesSafeTransArctic(t *testing.T) {
+	vertices := geo.PointSet{
+		*geo.NewPointFromLatLng(89.0, 0.0),
+		*geo.NewPointFromLatLng(88.0, 90.0),
+		*geo.NewPointFromLatLng(89.0, 180.0),
+		*geo.NewPointFromLatLng(88.0, -90.0),
+	}
+
+	r := NewRouteBoxer(50, vertices)
+	boxes := r.BoxesSafe()
+	if len(boxes) == 0 {
+		t.Fatal("expected at least one box for a trans-Arctic flight path")
+	}
+
+	for i, p := range vertices {
+		covered := false
+		for _, b := range boxes {
+			if boxContainsPoint(b, p) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			t.Errorf("waypoint %d (%v) is not covered by any box", i, p)
+		}
+	}
+
+	mid := geo.NewPointFromLatLng(88.5, 45.0)
+	covered := false
+	for _, b := range boxes {
+		if boxContainsPoint(b, *mid) {
+			covered = true
+			break
+		}
+	}
+	if !covered {
+		t.Error("midpoint of a sparse polar segment is not covered by any box; segment-fill left a hole in the corridor")
+	}
+}
+
+func boxContainsPoint(b geo.Bound, p geo.Point) bool {
+	return p.Lat() >= b.SouthWest().Lat() && p.Lat() <= b.NorthEast().Lat() &&
+		p.Lng() >= b.SouthWest().Lng() && p.Lng() <= b.NorthEast().Lng()
+}
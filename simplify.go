@@ -0,0 +1,110 @@
+package routeboxer
+
+import (
+	"math"
+
+	"github.com/paulmach/go.geo"
+)
+
+// SimplifyRoute runs Ramer-Douglas-Peucker simplification on vertices,
+// discarding points that lie within epsilonMeters of the great-circle chord
+// between the vertices either side of them. This was left as a TODO in the
+// original HERE port, and materially reduces the work
+// FindIntersectingCells has to do on dense GPS traces.
+func SimplifyRoute(vertices geo.PointSet, epsilonMeters float64) geo.PointSet {
+	if len(vertices) < 3 {
+		return vertices
+	}
+
+	keep := make([]bool, len(vertices))
+	keep[0] = true
+	keep[len(vertices)-1] = true
+
+	simplifyRange(vertices, 0, len(vertices)-1, epsilonMeters, keep)
+
+	simplified := make(geo.PointSet, 0, len(vertices))
+	for i, k := range keep {
+		if k {
+			simplified = append(simplified, vertices[i])
+		}
+	}
+	return simplified
+}
+
+// simplifyRange finds the vertex in (i, j) with the greatest perpendicular
+// great-circle distance from the chord P[i]P[j] and, if it is further away
+// than epsilonMeters, keeps it and recurses on the two halves either side of
+// it.
+func simplifyRange(vertices geo.PointSet, i, j int, epsilonMeters float64, keep []bool) {
+	if j <= i+1 {
+		return
+	}
+
+	maxDist := -1.0
+	maxIdx := -1
+
+	for k := i + 1; k < j; k++ {
+		d := crossTrackDistance(vertices[i], vertices[j], vertices[k])
+		if d > maxDist {
+			maxDist = d
+			maxIdx = k
+		}
+	}
+
+	if maxDist > epsilonMeters {
+		keep[maxIdx] = true
+		simplifyRange(vertices, i, maxIdx, epsilonMeters, keep)
+		simplifyRange(vertices, maxIdx, j, epsilonMeters, keep)
+	}
+}
+
+// crossTrackDistance returns the great-circle cross-track distance, in
+// metres, of p from the chord between start and end:
+//
+//	dxt = asin(sin(d13/R) * sin(θ13 − θ12)) * R
+//
+// where d13 is the great-circle distance from start to p, and θ12, θ13 are
+// the initial bearings from start to end and from start to p. If start and
+// end coincide the bearing to end is undefined, so this falls back to the
+// point-to-point distance from start to p.
+func crossTrackDistance(start, end, p geo.Point) float64 {
+	R := geo.EarthRadius
+
+	d13 := greatCircleDistance(start, p)
+	if d13 == 0 || greatCircleDistance(start, end) == 0 {
+		return d13
+	}
+
+	theta12 := initialBearing(start, end)
+	theta13 := initialBearing(start, p)
+
+	dxt := math.Asin(clamp(math.Sin(d13/R)*math.Sin(theta13-theta12), -1, 1)) * R
+
+	return math.Abs(dxt)
+}
+
+func greatCircleDistance(a, b geo.Point) float64 {
+	lat1, lat2 := deg2rad(a.Lat()), deg2rad(b.Lat())
+	dLat := deg2rad(b.Lat() - a.Lat())
+	dLng := deg2rad(b.Lng() - a.Lng())
+
+	sinDLat := math.Sin(dLat / 2)
+	sinDLng := math.Sin(dLng / 2)
+	h := sinDLat*sinDLat + math.Cos(lat1)*math.Cos(lat2)*sinDLng*sinDLng
+
+	return 2 * geo.EarthRadius * math.Asin(math.Sqrt(h))
+}
+
+func initialBearing(a, b geo.Point) float64 {
+	lat1, lat2 := deg2rad(a.Lat()), deg2rad(b.Lat())
+	dLng := deg2rad(b.Lng() - a.Lng())
+
+	y := math.Sin(dLng) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLng)
+
+	return math.Atan2(y, x)
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
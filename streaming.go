@@ -0,0 +1,445 @@
+package routeboxer
+
+import (
+	"math"
+
+	"github.com/paulmach/go.geo"
+)
+
+// StreamingRouteBoxer computes the same corridor boxes as RouteBoxer, but
+// without needing the whole route's bounds up front. Vertices are added one
+// at a time via AddVertex, the grid is extended on demand as the route moves
+// away from its origin, and marked cells are kept in a sparse set rather
+// than RouteBoxer's dense [][]int grid. This lets very long GPS traces
+// (marine tracks, long-haul trucking) be boxed without allocating a grid
+// sized to the full route, and lets callers pipeline boxes downstream as the
+// trace arrives by calling Flush: once the route has moved on from a row or
+// column by more than flushMargin cells, that row/column is settled, can no
+// longer be touched by markCell's neighbour padding or a future
+// markSegment, and Flush emits it and drops it from cells so it is never
+// returned again. Cells still within flushMargin of the current vertex are
+// left live and are only emitted once the route moves past them too, so
+// they are never settled by Flush alone; once the last vertex has been
+// added, call Close to drain everything still live, including that trailing
+// edge.
+type StreamingRouteBoxer struct {
+	distanceRange float64
+
+	origin    geo.Point
+	hasOrigin bool
+
+	latLines map[int]float64 // cached grid-line latitudes, keyed by steps north (+) or south (-) of origin
+	lngLines map[int]float64 // cached grid-line longitudes, keyed by steps east (+) or west (-) of origin
+
+	cells map[[2]int]struct{} // marked cells, keyed by (lngIndex, latIndex)
+
+	last   geo.Point
+	lastXY [2]int
+
+	maxXTouched, minXTouched int // furthest lngIndex any vertex has ever landed in
+	maxYTouched, minYTouched int // furthest latIndex any vertex has ever landed in
+
+	flushedBelowY    int // rows <= this have already been flushed out of cells
+	hasFlushedBelowY bool
+	flushedAboveY    int // rows >= this have already been flushed out of cells
+	hasFlushedAboveY bool
+	flushedBelowX    int // columns <= this have already been flushed out of cells
+	hasFlushedBelowX bool
+	flushedAboveX    int // columns >= this have already been flushed out of cells
+	hasFlushedAboveX bool
+}
+
+// flushMargin is how many rows/columns next to the route's current cell
+// Flush always leaves live; only rows/columns further behind are emitted:
+// markCell pads every
+// vertex's cell with its 8 neighbours, so a row/column within flushMargin of
+// the current vertex could still be touched by the very next AddVertex call.
+const flushMargin = 1
+
+func NewStreamingRouteBoxer(distanceRange float64) *StreamingRouteBoxer {
+	return &StreamingRouteBoxer{
+		distanceRange: distanceRange,
+		latLines:      map[int]float64{},
+		lngLines:      map[int]float64{},
+		cells:         map[[2]int]struct{}{},
+	}
+}
+
+// AddVertex extends the sparse grid to cover the new vertex, then marks the
+// cell it falls in (plus its 8 neighbours) and any cells the segment from the
+// previous vertex passes through, exactly as RouteBoxer.FindIntersectingCells
+// does against its dense grid.
+func (s *StreamingRouteBoxer) AddVertex(p geo.Point) {
+	if !s.hasOrigin {
+		s.origin = p
+		s.hasOrigin = true
+		s.lastXY = [2]int{0, 0}
+		s.last = p
+		s.markCell(s.lastXY)
+		return
+	}
+
+	xy := s.cellCoordsFromHint(p, s.last, s.lastXY)
+	s.markCell(xy)
+
+	if xy != s.lastXY {
+		s.markSegment(s.last, p, s.lastXY, xy)
+	}
+
+	s.last = p
+	s.lastXY = xy
+
+	if xy[0] > s.maxXTouched {
+		s.maxXTouched = xy[0]
+	}
+	if xy[0] < s.minXTouched {
+		s.minXTouched = xy[0]
+	}
+	if xy[1] > s.maxYTouched {
+		s.maxYTouched = xy[1]
+	}
+	if xy[1] < s.minYTouched {
+		s.minYTouched = xy[1]
+	}
+}
+
+// Flush merges and returns the boxes for any rows/columns that have become
+// settled since the last call (see the flushMargin doc on
+// StreamingRouteBoxer), removing their cells so they are never returned
+// again. It may return an empty result if nothing new has settled yet.
+func (s *StreamingRouteBoxer) Flush() RouteBoxerResult {
+	if len(s.cells) == 0 {
+		return RouteBoxerResult{}
+	}
+
+	result := RouteBoxerResult{}
+	x0, y0 := s.lastXY[0], s.lastXY[1]
+
+	// The route is currently at its northernmost/southernmost row reached so
+	// far, so whichever rows are more than flushMargin behind it are settled
+	// and can be emitted once. If y0 is neither extreme the route's future
+	// direction in this axis is still ambiguous, so nothing is flushed this
+	// call; it will be picked up once the route reaches an extreme again.
+	switch y0 {
+	case s.maxYTouched:
+		from := s.minYTouched
+		if s.hasFlushedBelowY {
+			from = s.flushedBelowY + 1
+		}
+		if to := y0 - flushMargin - 1; to >= from {
+			result = append(result, s.flushRows(from, to)...)
+			s.flushedBelowY, s.hasFlushedBelowY = to, true
+		}
+	case s.minYTouched:
+		to := s.maxYTouched
+		if s.hasFlushedAboveY {
+			to = s.flushedAboveY - 1
+		}
+		if from := y0 + flushMargin + 1; from <= to {
+			result = append(result, s.flushRows(from, to)...)
+			s.flushedAboveY, s.hasFlushedAboveY = from, true
+		}
+	}
+
+	switch x0 {
+	case s.maxXTouched:
+		from := s.minXTouched
+		if s.hasFlushedBelowX {
+			from = s.flushedBelowX + 1
+		}
+		if to := x0 - flushMargin - 1; to >= from {
+			result = append(result, s.flushColumns(from, to)...)
+			s.flushedBelowX, s.hasFlushedBelowX = to, true
+		}
+	case s.minXTouched:
+		to := s.maxXTouched
+		if s.hasFlushedAboveX {
+			to = s.flushedAboveX - 1
+		}
+		if from := x0 + flushMargin + 1; from <= to {
+			result = append(result, s.flushColumns(from, to)...)
+			s.flushedAboveX, s.hasFlushedAboveX = from, true
+		}
+	}
+
+	return result
+}
+
+// Close merges and returns the boxes for every cell still live, regardless
+// of flushMargin, and empties s.cells. Call it once the route's last vertex
+// has been added: Flush alone never settles the rows/columns within
+// flushMargin of wherever the route ended, since no further vertex is ever
+// going to arrive to carry the route safely past them.
+func (s *StreamingRouteBoxer) Close() RouteBoxerResult {
+	if len(s.cells) == 0 {
+		return RouteBoxerResult{}
+	}
+
+	_, _, minY, maxY := s.bounds()
+	return s.flushRows(minY, maxY)
+}
+
+// flushRows merges the marked cells in rows fromY..toY (inclusive) into
+// boxes the same way Flush's row pass always used to, then removes those
+// cells from s.cells so a later Flush never re-emits them.
+func (s *StreamingRouteBoxer) flushRows(fromY, toY int) RouteBoxerResult {
+	minX, maxX, _, _ := s.bounds()
+
+	boxes := []geo.Bound{}
+	var currentBox *geo.Bound
+	for y := fromY; y <= toY; y++ {
+		for x := minX; x <= maxX; x++ {
+			if s.marked(x, y) {
+				box := s.cellBounds(x, y)
+				if currentBox != nil {
+					currentBox.Extend(box.NorthEast())
+				} else {
+					currentBox = box
+				}
+			} else {
+				boxes = mergeBoxesInto(boxes, currentBox, true)
+				currentBox = nil
+			}
+		}
+		boxes = mergeBoxesInto(boxes, currentBox, true)
+		currentBox = nil
+	}
+
+	for y := fromY; y <= toY; y++ {
+		for x := minX; x <= maxX; x++ {
+			delete(s.cells, [2]int{x, y})
+		}
+	}
+
+	return boxes
+}
+
+// flushColumns is flushRows' column-wise counterpart, for columns fromX..toX.
+func (s *StreamingRouteBoxer) flushColumns(fromX, toX int) RouteBoxerResult {
+	_, _, minY, maxY := s.bounds()
+
+	boxes := []geo.Bound{}
+	var currentBox *geo.Bound
+	for x := fromX; x <= toX; x++ {
+		for y := minY; y <= maxY; y++ {
+			if s.marked(x, y) {
+				box := s.cellBounds(x, y)
+				if currentBox != nil {
+					currentBox.Extend(box.NorthEast())
+				} else {
+					currentBox = box
+				}
+			} else {
+				boxes = mergeBoxesInto(boxes, currentBox, false)
+				currentBox = nil
+			}
+		}
+		boxes = mergeBoxesInto(boxes, currentBox, false)
+		currentBox = nil
+	}
+
+	for x := fromX; x <= toX; x++ {
+		for y := minY; y <= maxY; y++ {
+			delete(s.cells, [2]int{x, y})
+		}
+	}
+
+	return boxes
+}
+
+func (s *StreamingRouteBoxer) bounds() (minX, maxX, minY, maxY int) {
+	first := true
+	for cell := range s.cells {
+		if first {
+			minX, maxX, minY, maxY = cell[0], cell[0], cell[1], cell[1]
+			first = false
+			continue
+		}
+		if cell[0] < minX {
+			minX = cell[0]
+		}
+		if cell[0] > maxX {
+			maxX = cell[0]
+		}
+		if cell[1] < minY {
+			minY = cell[1]
+		}
+		if cell[1] > maxY {
+			maxY = cell[1]
+		}
+	}
+	return
+}
+
+func (s *StreamingRouteBoxer) marked(x, y int) bool {
+	_, ok := s.cells[[2]int{x, y}]
+	return ok
+}
+
+func (s *StreamingRouteBoxer) cellBounds(x, y int) *geo.Bound {
+	return geo.NewBoundFromPoints(
+		geo.NewPointFromLatLng(s.latLine(y), s.lngLine(x)),
+		geo.NewPointFromLatLng(s.latLine(y+1), s.lngLine(x+1)),
+	)
+}
+
+// mergeBoxesInto is the sparse-grid equivalent of RouteBoxer.mergeBoxesX/
+// mergeBoxesY: it looks for an existing box in the given list that the new
+// box is adjacent to and spans the same columns/rows as, merging into it if
+// found, or appends the new box otherwise. alongRows selects whether the
+// adjacency check is row-wise (mergeBoxesY) or column-wise (mergeBoxesX).
+func mergeBoxesInto(boxes []geo.Bound, box *geo.Bound, alongRows bool) []geo.Bound {
+	if box == nil {
+		return boxes
+	}
+
+	for i := range boxes {
+		if alongRows {
+			if math.Abs(boxes[i].NorthEast().Lat()-box.SouthWest().Lat()) < 0.001 &&
+				math.Abs(boxes[i].SouthWest().Lng()-box.SouthWest().Lng()) < 0.001 &&
+				math.Abs(boxes[i].NorthEast().Lng()-box.NorthEast().Lng()) < 0.001 {
+				boxes[i].Extend(box.NorthEast())
+				return boxes
+			}
+		} else {
+			if math.Abs(boxes[i].NorthEast().Lng()-box.SouthWest().Lng()) < 0.001 &&
+				math.Abs(boxes[i].SouthWest().Lat()-box.SouthWest().Lat()) < 0.001 &&
+				math.Abs(boxes[i].NorthEast().Lat()-box.NorthEast().Lat()) < 0.001 {
+				boxes[i].Extend(box.NorthEast())
+				return boxes
+			}
+		}
+	}
+
+	return append(boxes, *box)
+}
+
+func (s *StreamingRouteBoxer) markCell(cell [2]int) {
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			s.cells[[2]int{cell[0] + dx, cell[1] + dy}] = struct{}{}
+		}
+	}
+}
+
+// markSegment marks every cell the path passes through between startXY and
+// endXY, for the case where the two vertices don't share a cell or an edge
+// between cells. It is the sparse-map port of RouteBoxer.getGridIntersects.
+func (s *StreamingRouteBoxer) markSegment(start, end geo.Point, startXY, endXY [2]int) {
+	if (startXY[0]-endXY[0] == 1 && startXY[1] == endXY[1]) ||
+		(endXY[0]-startXY[0] == 1 && startXY[1] == endXY[1]) ||
+		(startXY[0] == endXY[0] && startXY[1]-endXY[1] == 1) ||
+		(startXY[0] == endXY[0] && endXY[1]-startXY[1] == 1) {
+		// Adjacent cells share an edge; both were already marked directly.
+		return
+	}
+
+	brng := RhumBearingTo(start, end)
+	hint := start
+	hintXY := startXY
+
+	if end.Lat() > start.Lat() {
+		var i int
+		for i = startXY[1] + 1; i <= endXY[1]; i++ {
+			edgePoint := s.gridIntersect(start, brng, s.latLine(i))
+			edgeXY := s.cellCoordsFromHint(edgePoint, hint, hintXY)
+			s.fillRow(hintXY[0], edgeXY[0], i-1)
+			hint, hintXY = edgePoint, edgeXY
+		}
+		s.fillRow(hintXY[0], endXY[0], i-1)
+	} else {
+		var i int
+		for i = startXY[1]; i > endXY[1]; i-- {
+			edgePoint := s.gridIntersect(start, brng, s.latLine(i))
+			edgeXY := s.cellCoordsFromHint(edgePoint, hint, hintXY)
+			s.fillRow(hintXY[0], edgeXY[0], i)
+			hint, hintXY = edgePoint, edgeXY
+		}
+		s.fillRow(hintXY[0], endXY[0], i)
+	}
+}
+
+func (s *StreamingRouteBoxer) fillRow(startX, endX, y int) {
+	if startX < endX {
+		for x := startX; x <= endX; x++ {
+			s.markCell([2]int{x, y})
+		}
+	} else {
+		for x := startX; x >= endX; x-- {
+			s.markCell([2]int{x, y})
+		}
+	}
+}
+
+func (s *StreamingRouteBoxer) gridIntersect(start geo.Point, brng float64, gridLineLat float64) geo.Point {
+	d := (geo.EarthRadius / 1000) * ((deg2rad(gridLineLat) - deg2rad(start.Lat())) / math.Cos(deg2rad(brng)))
+	return *RhumbDestinationPoint(start, brng, d)
+}
+
+// cellCoordsFromHint is the sparse-map port of
+// RouteBoxer.getGridCoordsFromHint: it walks outward from a nearby known
+// cell rather than scanning the whole grid, extending latLines/lngLines on
+// demand as it goes.
+func (s *StreamingRouteBoxer) cellCoordsFromHint(p geo.Point, hintPoint geo.Point, hint [2]int) [2]int {
+	x, y := hint[0], hint[1]
+
+	if p.Lng() > hintPoint.Lng() {
+		for s.lngLine(x+1) < p.Lng() {
+			x++
+		}
+	} else {
+		for s.lngLine(x) > p.Lng() {
+			x--
+		}
+	}
+
+	if p.Lat() > hintPoint.Lat() {
+		for s.latLine(y+1) < p.Lat() {
+			y++
+		}
+	} else {
+		for s.latLine(y) > p.Lat() {
+			y--
+		}
+	}
+
+	return [2]int{x, y}
+}
+
+func (s *StreamingRouteBoxer) latLine(i int) float64 {
+	if v, ok := s.latLines[i]; ok {
+		return v
+	}
+
+	var v float64
+	switch {
+	case i == 0:
+		v = s.origin.Lat()
+	case i > 0:
+		v = RhumbDestinationPoint(s.origin, 0, s.distanceRange*float64(i)).Lat()
+	default:
+		v = RhumbDestinationPoint(s.origin, 180, s.distanceRange*float64(-i)).Lat()
+	}
+
+	s.latLines[i] = v
+	return v
+}
+
+func (s *StreamingRouteBoxer) lngLine(i int) float64 {
+	if v, ok := s.lngLines[i]; ok {
+		return v
+	}
+
+	var v float64
+	switch {
+	case i == 0:
+		v = s.origin.Lng()
+	case i > 0:
+		v = RhumbDestinationPoint(s.origin, 90, s.distanceRange*float64(i)).Lng()
+	default:
+		v = RhumbDestinationPoint(s.origin, 270, s.distanceRange*float64(-i)).Lng()
+	}
+
+	s.lngLines[i] = v
+	return v
+}
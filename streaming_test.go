@@ -0,0 +1,58 @@
+package routeboxer
+
+import (
+	"testing"
+
+	"github.com/paulmach/go.geo"
+)
+
+// TestStreamingRouteBoxerClose checks that Close drains the trailing edge of
+// the route that Flush alone leaves live: every vertex, including the last,
+// must end up covered by a box once AddVertex has been called for the whole
+// route and Close has been called once at the end.
+func TestStreamingRouteBoxerClose(t *testing.T) {
+	vertices := geo.PointSet{
+		*geo.NewPointFromLatLng(50.0, 10.0),
+		*geo.NewPointFromLatLng(50.1, 10.2),
+		*geo.NewPointFromLatLng(50.3, 10.3),
+		*geo.NewPointFromLatLng(50.6, 10.1),
+	}
+
+	s := NewStreamingRouteBoxer(50)
+	boxes := RouteBoxerResult{}
+	for _, p := range vertices {
+		s.AddVertex(p)
+		boxes = append(boxes, s.Flush()...)
+	}
+	boxes = append(boxes, s.Close()...)
+
+	for i, p := range vertices {
+		covered := false
+		for _, b := range boxes {
+			if boxContainsPoint(b, p) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			t.Errorf("vertex %d (%v) is not covered by any box after Close", i, p)
+		}
+	}
+}
+
+// TestStreamingRouteBoxerCloseDrainsEverything checks that Close empties
+// s.cells, leaving nothing behind for a later call to re-report.
+func TestStreamingRouteBoxerCloseDrainsEverything(t *testing.T) {
+	s := NewStreamingRouteBoxer(50)
+	s.AddVertex(*geo.NewPointFromLatLng(50.0, 10.0))
+	s.AddVertex(*geo.NewPointFromLatLng(50.01, 10.01))
+	s.Flush()
+
+	boxes := s.Close()
+	if len(boxes) == 0 {
+		t.Fatal("expected Close to return at least one box")
+	}
+	if len(s.cells) != 0 {
+		t.Error("expected Close to drain every remaining cell")
+	}
+}